@@ -35,6 +35,7 @@ package errors
 
 import (
 	"bytes"
+	stderrors "errors"
 	"fmt"
 	"reflect"
 	"runtime"
@@ -80,8 +81,9 @@ type DropboxError interface {
 // For an example of custom error type, look at databaseError/newDatabaseError
 // in errors_test.go.
 type baseError struct {
-	msg   string
-	inner error
+	msg    string
+	inner  error
+	fields map[string]interface{}
 
 	stack       []uintptr
 	framesOnce  sync.Once
@@ -118,6 +120,11 @@ func (e *baseError) Unwrap() error {
 	return e.inner
 }
 
+// This returns the structured fields attached to this error.
+func (e *baseError) GetFields() map[string]interface{} {
+	return e.fields
+}
+
 // Implements DropboxError interface.
 func (e *baseError) StackAddrs() string {
 	buf := bytes.NewBuffer(make([]byte, 0, len(e.stack)*8))
@@ -176,6 +183,82 @@ func Wrapf(err error, format string, args ...interface{}) DropboxError {
 	return newBaseError(err, fmt.Sprintf(format, args...))
 }
 
+// Custom DropboxError types implement this to keep their type when fields
+// are attached with WithFields. GetFields is used by Fields to collect the
+// fields from each error in the chain.
+type FieldsError interface {
+	GetFields() map[string]interface{}
+	WithFields(fields map[string]interface{}) DropboxError
+}
+
+// Returns a copy of err with the given fields attached, fields already on err
+// are kept unless overridden. Errors implementing FieldsError attach the
+// fields themselves, any other errors not created by this package are
+// wrapped in a new DropboxError with an empty message which carries the
+// fields.
+func WithFields(err error, fields map[string]interface{}) DropboxError {
+	if err == nil {
+		return nil
+	}
+
+	if fieldsErr, ok := err.(FieldsError); ok {
+		return fieldsErr.WithFields(fields)
+	}
+
+	baseErr, ok := err.(*baseError)
+	if !ok {
+		baseErr = newBaseError(err, "")
+	} else if baseErr == nil {
+		return nil
+	}
+
+	merged := make(map[string]interface{}, len(baseErr.fields)+len(fields))
+	for key, val := range baseErr.fields {
+		merged[key] = val
+	}
+	for key, val := range fields {
+		merged[key] = val
+	}
+
+	if !ok {
+		baseErr.fields = merged
+		return baseErr
+	}
+
+	return &baseError{
+		msg:    baseErr.msg,
+		inner:  baseErr.inner,
+		fields: merged,
+		stack:  baseErr.stack,
+	}
+}
+
+// Returns the fields attached to all errors in the chain. When the same key
+// is set at several levels the outermost error wins.
+func Fields(err error) map[string]interface{} {
+	var chain []error
+	for curErr := err; curErr != nil && len(chain) < 20; {
+		chain = append(chain, curErr)
+		curErr = Unwrap(curErr)
+	}
+
+	fields := map[string]interface{}{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		fieldsErr, ok := chain[i].(interface {
+			GetFields() map[string]interface{}
+		})
+		if !ok {
+			continue
+		}
+
+		for key, val := range fieldsErr.GetFields() {
+			fields[key] = val
+		}
+	}
+
+	return fields
+}
+
 // Internal helper function to create new baseError objects,
 // note that if there is more than one level of redirection to call this function,
 // stack frame information will include that level too.
@@ -199,10 +282,22 @@ func extractFullErrorMessage(e DropboxError, includeStack bool) string {
 	var lastDbxErr DropboxError
 	errMsg := bytes.NewBuffer(make([]byte, 0, 1024))
 
+	// Empty messages, such as errors only carrying fields, are skipped so
+	// they don't add blank lines.
+	writeMsg := func(msg string) {
+		if msg == "" {
+			return
+		}
+		if errMsg.Len() > 0 {
+			errMsg.WriteString("\n")
+		}
+		errMsg.WriteString(msg)
+	}
+
 	dbxErr := e
 	for {
 		lastDbxErr = dbxErr
-		errMsg.WriteString(dbxErr.GetMessage())
+		writeMsg(dbxErr.GetMessage())
 
 		innerErr := dbxErr.Unwrap()
 		if innerErr == nil {
@@ -212,11 +307,9 @@ func extractFullErrorMessage(e DropboxError, includeStack bool) string {
 		if !ok {
 			// We have reached the end and traveresed all inner errors.
 			// Add last message and exit loop.
-			errMsg.WriteString("\n")
-			errMsg.WriteString(innerErr.Error())
+			writeMsg(innerErr.Error())
 			break
 		}
-		errMsg.WriteString("\n")
 	}
 	if includeStack {
 		errMsg.WriteString("\nORIGINAL STACK TRACE:\n")
@@ -232,6 +325,11 @@ func unwrapError(ierr error) (nerr error) {
 		return dbxErr.Unwrap()
 	}
 
+	// Standard wrapped errors such as those from fmt.Errorf with %w.
+	if wrapErr, ok := ierr.(interface{ Unwrap() error }); ok {
+		return wrapErr.Unwrap()
+	}
+
 	// At this point, if anything goes wrong, just return nil.
 	defer func() {
 		if x := recover(); x != nil {
@@ -277,6 +375,37 @@ func RootDropboxError(dbxErr DropboxError) DropboxError {
 	return dbxErr
 }
 
+// Reports whether any error in err's chain matches target. DropboxError
+// implements Unwrap so this traverses all wrapped errors, same as the
+// standard errors.Is.
+func Is(err, target error) bool {
+	return stderrors.Is(err, target)
+}
+
+// Finds the first error in err's chain that matches target and if so sets
+// target to that error value, same as the standard errors.As.
+func As(err error, target interface{}) bool {
+	return stderrors.As(err, target)
+}
+
+// Returns the result of calling the Unwrap method on err, same as the
+// standard errors.Unwrap.
+func Unwrap(err error) error {
+	return stderrors.Unwrap(err)
+}
+
+// Returns the error directly wrapped by err or nil if there is none. This is
+// the single step RootError repeats, it follows DropboxError and standard
+// Unwrap methods and falls back to the Err field of Go system errors.
+func Cause(err error) error {
+	return unwrapError(err)
+}
+
+// Same as RootError, returns the deepest error in err's chain.
+func Root(err error) error {
+	return RootError(err)
+}
+
 // Perform a deep check, unwrapping errors as much as possilbe and
 // comparing the string version of the error.
 func IsError(err, errConst error) bool {
@@ -0,0 +1,180 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type databaseError struct {
+	DropboxError
+	fields map[string]interface{}
+}
+
+func newDatabaseError(msg string) *databaseError {
+	return &databaseError{
+		DropboxError: New(msg),
+	}
+}
+
+func (e *databaseError) GetFields() map[string]interface{} {
+	return e.fields
+}
+
+func (e *databaseError) WithFields(
+	fields map[string]interface{}) DropboxError {
+
+	merged := map[string]interface{}{}
+	for key, val := range e.fields {
+		merged[key] = val
+	}
+	for key, val := range fields {
+		merged[key] = val
+	}
+
+	return &databaseError{
+		DropboxError: e.DropboxError,
+		fields:       merged,
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	if WithFields(nil, map[string]interface{}{"a": 1}) != nil {
+		t.Error("errors: Expected nil for nil error")
+	}
+
+	baseErr := New("failed")
+	err := WithFields(baseErr, map[string]interface{}{
+		"a": 1,
+	})
+	err = WithFields(err, map[string]interface{}{
+		"a": 2,
+		"b": 3,
+	})
+
+	if GetMessage(err) != "failed" {
+		t.Errorf("errors: Bad message %q", GetMessage(err))
+	}
+	if len(Fields(baseErr)) != 0 {
+		t.Errorf("errors: Original error modified %v", Fields(baseErr))
+	}
+
+	expected := map[string]interface{}{
+		"a": 2,
+		"b": 3,
+	}
+	if !reflect.DeepEqual(Fields(err), expected) {
+		t.Errorf("errors: Expected %v, got %v", expected, Fields(err))
+	}
+}
+
+func TestWithFieldsForeign(t *testing.T) {
+	err := WithFields(io.EOF, map[string]interface{}{
+		"a": 1,
+	})
+
+	if GetMessage(err) != "EOF" {
+		t.Errorf("errors: Bad message %q", GetMessage(err))
+	}
+	if !Is(err, io.EOF) {
+		t.Error("errors: Wrapped error not found")
+	}
+
+	expected := map[string]interface{}{
+		"a": 1,
+	}
+	if !reflect.DeepEqual(Fields(err), expected) {
+		t.Errorf("errors: Expected %v, got %v", expected, Fields(err))
+	}
+}
+
+func TestWithFieldsCustom(t *testing.T) {
+	err := WithFields(newDatabaseError("query failed"),
+		map[string]interface{}{
+			"table": "users",
+		})
+
+	if _, ok := err.(*databaseError); !ok {
+		t.Fatalf("errors: Custom error type lost %T", err)
+	}
+	if GetMessage(err) != "query failed" {
+		t.Errorf("errors: Bad message %q", GetMessage(err))
+	}
+
+	wrapped := WithFields(Wrap(err, "outer"), map[string]interface{}{
+		"table": "groups",
+		"id":    1,
+	})
+
+	expected := map[string]interface{}{
+		"table": "groups",
+		"id":    1,
+	}
+	if !reflect.DeepEqual(Fields(wrapped), expected) {
+		t.Errorf("errors: Expected %v, got %v", expected, Fields(wrapped))
+	}
+}
+
+func TestGetMessageEmpty(t *testing.T) {
+	err := Wrap(Wrap(io.EOF, ""), "outer")
+	if GetMessage(err) != "outer\nEOF" {
+		t.Errorf("errors: Bad message %q", GetMessage(err))
+	}
+
+	err = Wrap(New("inner"), "")
+	if GetMessage(err) != "inner" {
+		t.Errorf("errors: Bad message %q", GetMessage(err))
+	}
+}
+
+func TestCause(t *testing.T) {
+	inner := New("inner")
+	if Cause(Wrap(inner, "outer")) != inner {
+		t.Error("errors: Bad DropboxError cause")
+	}
+
+	if Cause(fmt.Errorf("ctx: %w", io.EOF)) != io.EOF {
+		t.Error("errors: Bad wrapped error cause")
+	}
+
+	pathErr := &os.PathError{
+		Op:   "open",
+		Path: "/missing",
+		Err:  io.EOF,
+	}
+	if Cause(pathErr) != io.EOF {
+		t.Error("errors: Bad system error cause")
+	}
+
+	if Cause(io.EOF) != nil {
+		t.Error("errors: Expected nil cause")
+	}
+}
+
+func TestRoot(t *testing.T) {
+	err := Wrap(fmt.Errorf("ctx: %w", Wrap(io.EOF, "inner")), "outer")
+	if Root(err) != io.EOF {
+		t.Errorf("errors: Bad root %v", Root(err))
+	}
+	if RootError(err) != io.EOF {
+		t.Errorf("errors: Bad root error %v", RootError(err))
+	}
+
+	if Root(io.EOF) != io.EOF {
+		t.Error("errors: Expected error as its own root")
+	}
+}
+
+func TestIsError(t *testing.T) {
+	if !IsError(fmt.Errorf("a: %w", io.EOF), io.EOF) {
+		t.Error("errors: Wrapped error not matched")
+	}
+	if !IsError(Wrap(io.EOF, "outer"), io.EOF) {
+		t.Error("errors: DropboxError not matched")
+	}
+	if IsError(fmt.Errorf("a: %v", io.EOF), io.EOF) {
+		t.Error("errors: Formatted error matched")
+	}
+}
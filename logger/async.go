@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"io"
+	"sync"
+)
+
+type asyncEntry struct {
+	writer io.Writer
+	msg    []byte
+	flush  chan struct{}
+}
+
+var (
+	asyncQueue chan *asyncEntry
+	asyncDone  chan struct{}
+	asyncLock  = sync.RWMutex{}
+	outputLock = sync.Mutex{}
+)
+
+// Write entries from a background goroutine using a queue of bufSize
+// entries. Logging calls only block when the queue is full. Entries still
+// queued are lost if the process exits without calling Flush or Close,
+// including on a hard crash.
+func SetAsync(bufSize int) LoggerOption {
+	return func() {
+		Close()
+
+		if bufSize < 0 {
+			bufSize = 0
+		}
+
+		queue := make(chan *asyncEntry, bufSize)
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			for entry := range queue {
+				if entry.flush != nil {
+					close(entry.flush)
+					continue
+				}
+
+				writeOutput(entry.writer, entry.msg)
+			}
+		}()
+
+		asyncLock.Lock()
+		asyncQueue = queue
+		asyncDone = done
+		asyncLock.Unlock()
+	}
+}
+
+func Flush() {
+	flushSamples()
+	flushDedupe()
+
+	asyncLock.RLock()
+	if asyncQueue == nil {
+		asyncLock.RUnlock()
+		return
+	}
+
+	flush := make(chan struct{})
+	asyncQueue <- &asyncEntry{
+		flush: flush,
+	}
+	asyncLock.RUnlock()
+
+	<-flush
+}
+
+func Close() {
+	flushSamples()
+	flushDedupe()
+
+	asyncLock.Lock()
+	queue := asyncQueue
+	done := asyncDone
+	asyncQueue = nil
+	asyncDone = nil
+	asyncLock.Unlock()
+
+	if queue == nil {
+		return
+	}
+
+	close(queue)
+	<-done
+}
+
+func write(w io.Writer, msg []byte) {
+	asyncLock.RLock()
+	if asyncQueue != nil {
+		asyncQueue <- &asyncEntry{
+			writer: w,
+			msg:    msg,
+		}
+		asyncLock.RUnlock()
+		return
+	}
+	asyncLock.RUnlock()
+
+	writeOutput(w, msg)
+}
+
+// Each entry is fully formatted before this is called, including the
+// message, fields, error and stack trace. It is written with a single Write
+// under outputLock so entries from concurrent callers, the async goroutine
+// and dedupe summaries never interleave on a shared writer.
+func writeOutput(w io.Writer, msg []byte) {
+	outputLock.Lock()
+	_, _ = w.Write(msg)
+	outputLock.Unlock()
+}
@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const dedupeInterval = 5 * time.Second
+
+var (
+	dedupe        = false
+	dedupeLock    = sync.Mutex{}
+	dedupeKey     string
+	dedupeLevel   string
+	dedupeWriter  io.Writer
+	dedupeRepeats int
+	dedupeTimer   *time.Timer
+)
+
+func SetDedupe(enabled bool) LoggerOption {
+	return func() {
+		flushDedupe()
+		dedupe = enabled
+	}
+}
+
+func dedupeSummary() {
+	if dedupeRepeats == 0 {
+		return
+	}
+
+	entry := &Entry{
+		Level: dedupeLevel,
+		Message: fmt.Sprintf(
+			"Last message repeated %d times", dedupeRepeats),
+		Time: now(),
+	}
+	entry.Data = entry.fields()
+
+	msg, err := formatter.Format(entry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logger: Format error: %s\n", err)
+	} else {
+		write(dedupeWriter, msg)
+	}
+
+	dedupeRepeats = 0
+}
+
+// Writers are compared as interface values, writers with a type that can't be
+// compared are never treated as the same destination.
+func sameWriter(a, b io.Writer) bool {
+	typ := reflect.TypeOf(a)
+	if typ == nil || typ != reflect.TypeOf(b) || !typ.Comparable() {
+		return false
+	}
+	return a == b
+}
+
+func checkDedupe(e *Entry, w io.Writer) (duplicate bool) {
+	if !dedupe {
+		return
+	}
+
+	key := e.Level + "\x00" + e.Message + "\x00" + fmt.Sprint(e.Data)
+
+	dedupeLock.Lock()
+	defer dedupeLock.Unlock()
+
+	if key == dedupeKey && sameWriter(w, dedupeWriter) {
+		dedupeRepeats += 1
+		if dedupeTimer == nil {
+			dedupeTimer = time.AfterFunc(dedupeInterval, flushDedupe)
+		}
+		duplicate = true
+		return
+	}
+
+	dedupeSummary()
+	if dedupeTimer != nil {
+		dedupeTimer.Stop()
+		dedupeTimer = nil
+	}
+
+	dedupeKey = key
+	dedupeLevel = e.Level
+	dedupeWriter = w
+
+	return
+}
+
+func flushDedupe() {
+	dedupeLock.Lock()
+	defer dedupeLock.Unlock()
+
+	dedupeSummary()
+	if dedupeTimer != nil {
+		dedupeTimer.Stop()
+		dedupeTimer = nil
+	}
+
+	dedupeKey = ""
+	dedupeLevel = ""
+	dedupeWriter = nil
+}
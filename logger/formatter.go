@@ -0,0 +1,17 @@
+package logger
+
+type Formatter interface {
+	Format(*Entry) ([]byte, error)
+}
+
+type TextFormatter struct{}
+
+func (f TextFormatter) Format(e *Entry) ([]byte, error) {
+	return []byte(e.formatText()), nil
+}
+
+type JSONFormatter struct{}
+
+func (f JSONFormatter) Format(e *Entry) ([]byte, error) {
+	return e.formatJSON()
+}
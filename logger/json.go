@@ -0,0 +1,158 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pritunl/tools/errors"
+)
+
+var (
+	jsonMsg  = "message"
+	jsonLvl  = "level"
+	jsonTime = "time"
+	errChain = false
+)
+
+func SetJSON(enabled bool) LoggerOption {
+	return func() {
+		if enabled {
+			formatter = JSONFormatter{}
+		} else {
+			formatter = TextFormatter{}
+		}
+	}
+}
+
+func SetJSONKeys(msgKey, levelKey, timeKey string) LoggerOption {
+	return func() {
+		jsonMsg = msgKey
+		jsonLvl = levelKey
+		jsonTime = timeKey
+	}
+}
+
+var baseErrorType = fmt.Sprintf("%T", errors.New(""))
+
+func SetErrorChain(enabled bool) LoggerOption {
+	return func() {
+		errChain = enabled
+	}
+}
+
+func jsonErrorChain(err error) []map[string]string {
+	chain := []map[string]string{}
+
+	for curErr := err; curErr != nil && len(chain) < 20; {
+		link := map[string]string{}
+
+		if dbxErr, ok := curErr.(errors.DropboxError); ok {
+			// Only custom DropboxError types carry a useful type name
+			if typ := fmt.Sprintf("%T", curErr); typ != baseErrorType {
+				link["type"] = typ
+			}
+			link["message"] = dbxErr.GetMessage()
+		} else {
+			link["message"] = curErr.Error()
+		}
+
+		chain = append(chain, link)
+		curErr = errors.Unwrap(curErr)
+	}
+
+	return chain
+}
+
+func jsonValue(val interface{}) json.RawMessage {
+	data, err := json.Marshal(val)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%#v", val))
+	}
+	return data
+}
+
+func jsonTimestamp(timestamp time.Time) interface{} {
+	if timeUtc {
+		timestamp = timestamp.UTC()
+	}
+
+	if timeMode == TimeEpoch {
+		return timestamp.UnixNano()
+	}
+	return timestamp.Format(time.RFC3339Nano)
+}
+
+// Fields using a key reserved for the entry are moved under a fields.
+// prefix so they aren't overwritten.
+func jsonFieldKey(key string) string {
+	switch key {
+	case jsonMsg, jsonLvl, jsonTime:
+		return "fields." + key
+	case "prefix":
+		if prefix != "" {
+			return "fields." + key
+		}
+	}
+	return key
+}
+
+func (e *Entry) formatJSON() ([]byte, error) {
+	record := map[string]json.RawMessage{}
+
+	for key, val := range e.Data {
+		name := jsonFieldKey(key)
+
+		if key == "error" {
+			err, ok := val.(error)
+			if ok && errChain && !isNilError(err) {
+				record[name] = jsonValue(jsonErrorChain(err))
+			} else {
+				record[name] = jsonValue(formatError(val))
+			}
+			continue
+		}
+		if timeFields {
+			switch v := val.(type) {
+			case time.Duration:
+				record[name] = jsonValue(v.String())
+				continue
+			case time.Time:
+				if fieldLayout != "" {
+					record[name] = jsonValue(formatTimeField(v))
+				} else {
+					record[name] = jsonValue(jsonTimestamp(v))
+				}
+				continue
+			}
+		}
+
+		record[name] = jsonValue(val)
+	}
+
+	if prefix != "" {
+		record["prefix"] = jsonValue(prefix)
+	}
+
+	record[jsonTime] = jsonValue(jsonTimestamp(e.Time))
+	record[jsonLvl] = jsonValue(e.Level)
+	record[jsonMsg] = jsonValue(e.Message)
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	if newline {
+		data = append(data, '\n')
+	}
+
+	return data, nil
+}
+
+func resetJSON() {
+	jsonMsg = "message"
+	jsonLvl = "level"
+	jsonTime = "time"
+	errChain = false
+}
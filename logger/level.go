@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pritunl/tools/errors"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+	LevelPanic
+)
+
+var levels = map[string]Level{
+	DebugLevel: LevelDebug,
+	InfoLevel:  LevelInfo,
+	WarnLevel:  LevelWarn,
+	ErrorLevel: LevelError,
+	FatalLevel: LevelFatal,
+	PanicLevel: LevelPanic,
+}
+
+var levelNames = map[Level]string{
+	LevelDebug: DebugLevel,
+	LevelInfo:  InfoLevel,
+	LevelWarn:  WarnLevel,
+	LevelError: ErrorLevel,
+	LevelFatal: FatalLevel,
+	LevelPanic: PanicLevel,
+}
+
+func (l Level) String() string {
+	name, ok := levelNames[l]
+	if !ok {
+		return strconv.Itoa(int(l))
+	}
+	return name
+}
+
+func ParseLevel(level string) (Level, error) {
+	level = strings.ToLower(strings.TrimSpace(level))
+
+	lvl, ok := levels[level]
+	if ok {
+		return lvl, nil
+	}
+
+	if level == "warning" {
+		return LevelWarn, nil
+	}
+
+	num, err := strconv.Atoi(level)
+	if err == nil {
+		lvl = Level(num)
+		if _, ok = levelNames[lvl]; ok {
+			return lvl, nil
+		}
+	}
+
+	return LevelDebug, errors.Newf(
+		"logger: Invalid log level '%s'", level)
+}
+
+func Enabled(level string) bool {
+	return levelValue(level) >= minLevel
+}
+
+func levelValue(level string) Level {
+	lvl, ok := levels[level]
+	if !ok {
+		return LevelInfo
+	}
+	return lvl
+}
@@ -0,0 +1,198 @@
+package logger
+
+import (
+	"container/list"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	MaxLimit      = 24 * time.Hour
+	MaxLimits     = 10000
+	limitsCleanup = 16
+)
+
+type limitEntry struct {
+	key        string
+	until      time.Time
+	expire     time.Time
+	count      int
+	suppressed int
+	pending    *Entry
+}
+
+var (
+	limits         = map[string]*list.Element{}
+	limitsList     = list.New()
+	limitsLock     = sync.Mutex{}
+	limitsPending  []*Entry
+	sampleInterval = 5 * time.Second
+	sampleTimer    *time.Timer
+)
+
+func limitToken(msg string) string {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(msg))
+	return strconv.FormatUint(hash.Sum64(), 36)
+}
+
+func getLimit(key string, timestamp time.Time) (entry *limitEntry) {
+	cleanLimits(timestamp)
+
+	elem, ok := limits[key]
+	if ok {
+		limitsList.MoveToFront(elem)
+		entry = elem.Value.(*limitEntry)
+		return
+	}
+
+	entry = &limitEntry{
+		key: key,
+	}
+	limits[key] = limitsList.PushFront(entry)
+
+	for limitsList.Len() > MaxLimits {
+		removeLimit(limitsList.Back())
+	}
+
+	return
+}
+
+func checkLimit(key string, dur time.Duration) bool {
+	if dur > MaxLimit {
+		dur = MaxLimit
+	}
+
+	timestamp := now()
+
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+
+	entry := getLimit(key, timestamp)
+	if timestamp.Before(entry.until) {
+		return false
+	}
+
+	entry.until = timestamp.Add(dur)
+	if entry.expire.Before(entry.until) {
+		entry.expire = entry.until
+	}
+
+	return true
+}
+
+func checkSample(e *Entry, key string, n int) (
+	emit bool, suppressed int) {
+
+	timestamp := now()
+
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+
+	entry := getLimit(key, timestamp)
+	if entry.count%n == 0 {
+		emit = true
+		suppressed = entry.suppressed
+		entry.suppressed = 0
+		entry.pending = nil
+	} else {
+		pending := *e
+		entry.suppressed += 1
+		entry.pending = &pending
+
+		if sampleTimer == nil {
+			sampleTimer = time.AfterFunc(sampleInterval, flushSamples)
+		}
+	}
+
+	entry.count += 1
+	if entry.expire.Before(timestamp.Add(MaxLimit)) {
+		entry.expire = timestamp.Add(MaxLimit)
+	}
+
+	return
+}
+
+// Queues a summary of the suppressed sample entries, must be called with
+// limitsLock held and the queue emitted with outputPending after unlocking.
+func queueSuppressed(entry *limitEntry) {
+	if entry.suppressed == 0 || entry.pending == nil {
+		return
+	}
+
+	pending := *entry.pending
+	data := Fields{}
+	for key, val := range pending.Data {
+		data[key] = val
+	}
+	data["suppressed"] = entry.suppressed
+	pending.Data = data
+	pending.Time = now()
+
+	limitsPending = append(limitsPending, &pending)
+
+	entry.suppressed = 0
+	entry.pending = nil
+}
+
+func outputPending() {
+	limitsLock.Lock()
+	pending := limitsPending
+	limitsPending = nil
+	limitsLock.Unlock()
+
+	for _, entry := range pending {
+		entry.output()
+	}
+}
+
+func flushSamples() {
+	limitsLock.Lock()
+	if sampleTimer != nil {
+		sampleTimer.Stop()
+		sampleTimer = nil
+	}
+	for elem := limitsList.Front(); elem != nil; elem = elem.Next() {
+		queueSuppressed(elem.Value.(*limitEntry))
+	}
+	limitsLock.Unlock()
+
+	outputPending()
+}
+
+func removeLimit(elem *list.Element) {
+	entry := limitsList.Remove(elem).(*limitEntry)
+	delete(limits, entry.key)
+	queueSuppressed(entry)
+}
+
+// Removes a bounded number of expired entries from the least recently used
+// end of the list to avoid scanning all limits on the logging path.
+func cleanLimits(timestamp time.Time) {
+	for i := 0; i < limitsCleanup; i++ {
+		elem := limitsList.Back()
+		if elem == nil {
+			return
+		}
+
+		if timestamp.Before(elem.Value.(*limitEntry).expire) {
+			return
+		}
+
+		removeLimit(elem)
+	}
+}
+
+func resetLimits() {
+	limitsLock.Lock()
+	limits = map[string]*list.Element{}
+	limitsList = list.New()
+	limitsPending = nil
+	if sampleTimer != nil {
+		sampleTimer.Stop()
+		sampleTimer = nil
+	}
+	limitsLock.Unlock()
+}
@@ -1,10 +1,18 @@
 package logger
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/pritunl/tools/errors"
 )
 
 const (
@@ -12,34 +20,244 @@ const (
 	InfoLevel  = "info"
 	WarnLevel  = "warn"
 	ErrorLevel = "error"
+	FatalLevel = "fatal"
+	PanicLevel = "panic"
 )
 
+const (
+	TimeLayout TimeMode = iota
+	TimeRFC3339
+	TimeEpoch
+)
+
+const (
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+var levelColors = map[string]string{
+	DebugLevel: "\x1b[90m",
+	InfoLevel:  "\x1b[36m",
+	WarnLevel:  "\x1b[33m",
+	ErrorLevel: "\x1b[31m",
+	FatalLevel: "\x1b[1;31m",
+	PanicLevel: "\x1b[1;31m",
+}
+
 var (
 	timeFormat  = "[2006-01-02 15:04:05]"
 	levelFormat = "[%s]"
 	showIcons   = true
+	output      = io.Writer(os.Stdout)
+	errOutput   io.Writer
+	now         = time.Now
+	defaults    Fields
+	colorMode   = ColorNever
+	hooks       []Hook
+	minLevel    = LevelDebug
+	fieldFormat func(key string, val interface{}) string
+	ctxFields   []contextField
+	levelIcons  map[string]string
+	indent      string
+	timeMode    = TimeLayout
+	timeUtc     = false
+	stackTraces = true
+	newline     = true
+	flatten     = false
+	initOnce    = sync.Once{}
+	formatter   = Formatter(TextFormatter{})
+	prefix      string
+	errorArgs   = true
+	exitFunc    = os.Exit
+	timeFields  = true
+	processors  []func(*Entry) bool
+	fieldLayout string
 )
 
 type LoggerOption func()
 
+type TimeMode int
+
+type ColorMode int
+
+type contextField struct {
+	name string
+	key  interface{}
+}
+
+type Hook interface {
+	Levels() []string
+	Fire(*Entry) error
+}
+
+func SetPrefix(str string) LoggerOption {
+	return func() {
+		prefix = str
+	}
+}
+
 func SetTimeFormat(format string) LoggerOption {
 	return func() {
 		timeFormat = format
 	}
 }
 
+func SetTimeMode(mode TimeMode) LoggerOption {
+	return func() {
+		timeMode = mode
+	}
+}
+
+func SetUTC(utc bool) LoggerOption {
+	return func() {
+		timeUtc = utc
+	}
+}
+
 func SetLevelFormat(format string) LoggerOption {
 	return func() {
 		levelFormat = format
 	}
 }
 
+func SetLevel(level Level) LoggerOption {
+	return func() {
+		minLevel = level
+	}
+}
+
+func SetOutput(w io.Writer) LoggerOption {
+	return func() {
+		output = w
+	}
+}
+
+func SetErrorOutput(w io.Writer) LoggerOption {
+	return func() {
+		errOutput = w
+	}
+}
+
+func SetNowFunc(fn func() time.Time) LoggerOption {
+	return func() {
+		now = fn
+	}
+}
+
+func SetDefaultFields(fields Fields) LoggerOption {
+	return func() {
+		defaults = Fields{}
+		for key, val := range fields {
+			defaults[key] = val
+		}
+	}
+}
+
+func SetColor(mode ColorMode) LoggerOption {
+	return func() {
+		colorMode = mode
+	}
+}
+
+func AddHook(hook Hook) LoggerOption {
+	return func() {
+		hooks = append(hooks, hook)
+	}
+}
+
+func SetFieldFormatter(
+	formatter func(key string, val interface{}) string) LoggerOption {
+
+	return func() {
+		fieldFormat = formatter
+	}
+}
+
+func RegisterContextField(name string, key interface{}) LoggerOption {
+	return func() {
+		ctxFields = append(ctxFields, contextField{
+			name: name,
+			key:  key,
+		})
+	}
+}
+
+func SetMultilineIndent(prefix string) LoggerOption {
+	return func() {
+		indent = prefix
+	}
+}
+
+func SetStackTraces(show bool) LoggerOption {
+	return func() {
+		stackTraces = show
+	}
+}
+
+func SetAppendNewline(enabled bool) LoggerOption {
+	return func() {
+		newline = enabled
+	}
+}
+
+func SetFlattenFields(enabled bool) LoggerOption {
+	return func() {
+		flatten = enabled
+	}
+}
+
+func SetFormatter(fmtr Formatter) LoggerOption {
+	return func() {
+		formatter = fmtr
+	}
+}
+
+func SetErrorArgs(enabled bool) LoggerOption {
+	return func() {
+		errorArgs = enabled
+	}
+}
+
+func SetExitFunc(fn func(int)) LoggerOption {
+	return func() {
+		exitFunc = fn
+	}
+}
+
+func SetTimeFields(enabled bool) LoggerOption {
+	return func() {
+		timeFields = enabled
+	}
+}
+
+func SetTimeFieldFormat(layout string) LoggerOption {
+	return func() {
+		fieldLayout = layout
+	}
+}
+
+func AddProcessor(processor func(*Entry) bool) LoggerOption {
+	return func() {
+		processors = append(processors, processor)
+	}
+}
+
 func SetIcons(show bool) LoggerOption {
 	return func() {
 		showIcons = show
 	}
 }
 
+func SetLevelIcons(icons map[string]string) LoggerOption {
+	return func() {
+		levelIcons = map[string]string{}
+		for level, icon := range icons {
+			levelIcons[level] = icon
+		}
+	}
+}
+
 type Fields map[string]interface{}
 
 type Entry struct {
@@ -47,6 +265,51 @@ type Entry struct {
 	Message string
 	Time    time.Time
 	Data    Fields
+
+	limit    time.Duration
+	limitKey string
+	sample   int
+	dest     io.Writer
+	minLevel *Level
+	at       time.Time
+}
+
+func (e *Entry) Limit(dur time.Duration) *Entry {
+	e.limit = dur
+	return e
+}
+
+func (e *Entry) Sample(n int) *Entry {
+	e.sample = n
+	return e
+}
+
+func (e *Entry) LimitKey(key string) *Entry {
+	e.limitKey = key
+	return e
+}
+
+func (e *Entry) With(fields Fields) *Entry {
+	data := make(Fields, len(e.Data)+len(fields))
+	for key, val := range e.Data {
+		data[key] = val
+	}
+	for key, val := range fields {
+		data[key] = val
+	}
+	e.Data = data
+
+	return e
+}
+
+func (e *Entry) At(timestamp time.Time) *Entry {
+	e.at = timestamp
+	return e
+}
+
+func (e *Entry) ToWriter(w io.Writer) *Entry {
+	e.dest = w
+	return e
 }
 
 func (e *Entry) Debug(args ...interface{}) {
@@ -65,35 +328,154 @@ func (e *Entry) Error(args ...interface{}) {
 	e.log(ErrorLevel, args...)
 }
 
+func (e *Entry) Fatal(args ...interface{}) {
+	e.log(FatalLevel, args...)
+	Flush()
+	exitFunc(1)
+}
+
+func (e *Entry) Panic(args ...interface{}) {
+	e.log(PanicLevel, args...)
+	Flush()
+	panic(e.Message)
+}
+
 func (e *Entry) log(level string, args ...interface{}) {
+	switch {
+	case level == FatalLevel || level == PanicLevel:
+		// Never filtered since the caller exits or panics after logging
+	case e.minLevel != nil:
+		if levelValue(level) < *e.minLevel {
+			return
+		}
+	case !Enabled(level):
+		return
+	}
+
+	if errorArgs && len(args) > 0 {
+		args = e.extractError(args)
+	}
+
 	e.Level = level
 	e.Message = fmt.Sprint(args...)
-	e.Time = time.Now()
+	if e.at.IsZero() {
+		e.Time = now()
+	} else {
+		e.Time = e.at
+	}
+
+	if e.limit > 0 || e.sample > 1 {
+		defer outputPending()
+
+		key := e.limitKey
+		if key == "" {
+			key = limitToken(e.Message)
+		}
+
+		if e.limit > 0 && !checkLimit(key, e.limit) {
+			return
+		}
+
+		if e.sample > 1 {
+			emit, suppressed := checkSample(e, key, e.sample)
+			if !emit {
+				return
+			}
+
+			if suppressed > 0 {
+				data := Fields{}
+				for k, v := range e.Data {
+					data[k] = v
+				}
+				data["suppressed"] = suppressed
+				e.Data = data
+			}
+		}
+	}
+
 	e.output()
 }
 
+func (e *Entry) extractError(args []interface{}) []interface{} {
+	err, ok := args[len(args)-1].(error)
+	if !ok || isNilError(err) {
+		return args
+	}
+
+	if _, exists := e.Data["error"]; exists {
+		return args
+	}
+
+	data := Fields{}
+	for key, val := range e.Data {
+		data[key] = val
+	}
+	data["error"] = err
+	e.Data = data
+
+	args = args[:len(args)-1]
+	if len(args) == 0 {
+		if dbxErr, ok := err.(errors.DropboxError); ok {
+			args = []interface{}{dbxErr.GetMessage()}
+		} else {
+			args = []interface{}{err.Error()}
+		}
+	}
+
+	return args
+}
+
 func (e *Entry) output() {
-	var msg string
-	if timeFormat != "" {
-		msg += e.Time.Format(timeFormat)
+	e.Data = e.fields()
+
+	for _, processor := range processors {
+		if !processor(e) {
+			return
+		}
 	}
+
+	msg, err := formatter.Format(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logger: Format error: %s\n", err)
+	} else if w := e.writer(); !checkDedupe(e, w) {
+		write(w, msg)
+	}
+
+	e.fire()
+}
+
+func (e *Entry) formatText() string {
+	msg := prefix
+	msg += formatTime(e.Time)
 	if levelFormat != "" {
-		msg += fmt.Sprintf(levelFormat, strings.ToUpper(e.Level))
+		level := fmt.Sprintf(levelFormat, strings.ToUpper(e.Level))
+		if color := levelColors[e.Level]; color != "" && e.color() {
+			level = color + level + "\x1b[0m"
+		}
+		msg += level
 	}
 	if msg != "" {
 		msg += " "
 	}
 	if showIcons {
-		msg += "▶ "
+		icon, ok := levelIcons[e.Level]
+		if !ok {
+			icon = "▶"
+		}
+		msg += icon + " "
 	}
-	msg += e.Message
+	msg += indentLines(e.Message)
 
+	data := e.Data
+	if flatten {
+		data = flattenFields(data)
+	}
 	keys := []string{}
 
 	var errStr string
-	for key, val := range e.Data {
+	for key, val := range data {
 		if key == "error" {
-			errStr = fmt.Sprintf("%s", val)
+			errStr = formatError(val)
 			continue
 		}
 
@@ -105,22 +487,226 @@ func (e *Entry) output() {
 	for _, key := range keys {
 		if showIcons {
 			msg += fmt.Sprintf(" ◆ %s=%v", key,
-				fmt.Sprintf("%#v", e.Data[key]))
+				formatField(key, data[key]))
 		} else {
 			msg += fmt.Sprintf(" %s=%v", key,
-				fmt.Sprintf("%#v", e.Data[key]))
+				formatField(key, data[key]))
 		}
 	}
 
 	if errStr != "" {
-		msg += "\n" + errStr
+		msg += "\n" + indent + indentLines(errStr)
 	}
 
-	if string(msg[len(msg)-1]) != "\n" {
+	if newline && !strings.HasSuffix(msg, "\n") {
 		msg += "\n"
 	}
 
-	fmt.Print(msg)
+	return msg
+}
+
+func (e *Entry) fire() {
+	for _, hook := range hooks {
+		fire := false
+		for _, level := range hook.Levels() {
+			if level == e.Level {
+				fire = true
+				break
+			}
+		}
+		if !fire {
+			continue
+		}
+
+		err := hook.Fire(e)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "logger: Hook error: %s\n", err)
+		}
+	}
+}
+
+func formatTime(timestamp time.Time) string {
+	if timeMode == TimeLayout {
+		if timeFormat == "" {
+			return ""
+		}
+		return formatTimeValue(timestamp)
+	}
+	return "[" + formatTimeValue(timestamp) + "]"
+}
+
+func formatTimeValue(timestamp time.Time) string {
+	if timeUtc {
+		timestamp = timestamp.UTC()
+	}
+
+	switch timeMode {
+	case TimeRFC3339:
+		return timestamp.Format(time.RFC3339Nano)
+	case TimeEpoch:
+		return strconv.FormatInt(timestamp.UnixNano(), 10)
+	default:
+		if timeFormat == "" {
+			return timestamp.Format(time.RFC3339Nano)
+		}
+		return timestamp.Format(timeFormat)
+	}
+}
+
+// Field times don't use the line time layout since it is usually
+// decorated, they default to RFC3339 unless a field layout is set.
+func formatTimeField(timestamp time.Time) string {
+	if timeUtc {
+		timestamp = timestamp.UTC()
+	}
+
+	switch {
+	case fieldLayout != "":
+		return timestamp.Format(fieldLayout)
+	case timeMode == TimeEpoch:
+		return strconv.FormatInt(timestamp.UnixNano(), 10)
+	default:
+		return timestamp.Format(time.RFC3339Nano)
+	}
+}
+
+func indentLines(str string) string {
+	if indent == "" {
+		return str
+	}
+	return strings.ReplaceAll(strings.TrimRight(str, "\n"), "\n", "\n"+indent)
+}
+
+func flattenFields(data Fields) Fields {
+	flat := Fields{}
+	for key, val := range data {
+		flattenField(flat, key, val)
+	}
+	return flat
+}
+
+func flattenField(flat Fields, key string, val interface{}) {
+	valRef := reflect.ValueOf(val)
+	if valRef.Kind() != reflect.Map ||
+		valRef.Type().Key().Kind() != reflect.String ||
+		valRef.Len() == 0 {
+
+		flat[key] = val
+		return
+	}
+
+	iter := valRef.MapRange()
+	for iter.Next() {
+		flattenField(flat, key+"."+iter.Key().String(),
+			iter.Value().Interface())
+	}
+}
+
+// Typed nil pointers in an error panic when their methods are called
+// directly, these are left to fmt which recovers and renders <nil>.
+func isNilError(err error) bool {
+	val := reflect.ValueOf(err)
+	return val.Kind() == reflect.Ptr && val.IsNil()
+}
+
+func formatError(val interface{}) string {
+	err, ok := val.(error)
+	if !ok || isNilError(err) {
+		return fmt.Sprintf("%s", val)
+	}
+
+	if dbxErr, ok := err.(errors.DropboxError); ok {
+		if stackTraces {
+			return dbxErr.Error()
+		}
+		return errors.GetMessage(dbxErr)
+	}
+
+	// Standard errors wrapping a DropboxError already include its stack
+	// trace in their message, swap it for the message without it.
+	errStr := err.Error()
+	if !stackTraces {
+		var dbxErr errors.DropboxError
+		if errors.As(err, &dbxErr) && !isNilError(dbxErr) {
+			errStr = strings.Replace(errStr, dbxErr.Error(),
+				errors.GetMessage(dbxErr), 1)
+		}
+	}
+
+	return errStr
+}
+
+func formatField(key string, val interface{}) string {
+	if fieldFormat != nil {
+		return fieldFormat(key, val)
+	}
+
+	if timeFields {
+		switch v := val.(type) {
+		case time.Duration:
+			return v.String()
+		case time.Time:
+			return formatTimeField(v)
+		}
+	}
+
+	return fmt.Sprintf("%#v", val)
+}
+
+// Default fields are merged into a copy of Data before the entry is output
+// so processors, formatters and hooks all see the same fields.
+func (e *Entry) fields() Fields {
+	if len(defaults) == 0 {
+		return e.Data
+	}
+
+	data := Fields{}
+	for key, val := range defaults {
+		data[key] = val
+	}
+	for key, val := range e.Data {
+		data[key] = val
+	}
+
+	return data
+}
+
+func (e *Entry) color() bool {
+	switch colorMode {
+	case ColorAlways:
+		return true
+	case ColorAuto:
+		return isTerminal(e.writer())
+	default:
+		return false
+	}
+}
+
+func (e *Entry) writer() io.Writer {
+	if e.dest != nil {
+		return e.dest
+	}
+	if errOutput != nil {
+		switch e.Level {
+		case WarnLevel, ErrorLevel, FatalLevel, PanicLevel:
+			return errOutput
+		}
+	}
+	return output
+}
+
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
 }
 
 func WithFields(fields Fields) *Entry {
@@ -129,28 +715,128 @@ func WithFields(fields Fields) *Entry {
 	}
 }
 
+func WithContext(ctx context.Context) *Entry {
+	data := Fields{}
+	for _, field := range ctxFields {
+		val := ctx.Value(field.key)
+		if val == nil {
+			continue
+		}
+		data[field.name] = val
+	}
+
+	return &Entry{
+		Data: data,
+	}
+}
+
+func WithError(err error) *Entry {
+	data := Fields{}
+	if !isNilError(err) {
+		for key, val := range errors.Fields(err) {
+			data[key] = val
+		}
+	}
+	data["error"] = err
+
+	return &Entry{
+		Data: data,
+	}
+}
+
 func Debug(args ...interface{}) {
+	if !Enabled(DebugLevel) {
+		return
+	}
+
 	entry := &Entry{}
 	entry.Debug(args...)
 }
 
 func Info(args ...interface{}) {
+	if !Enabled(InfoLevel) {
+		return
+	}
+
 	entry := &Entry{}
 	entry.Info(args...)
 }
 
 func Warn(args ...interface{}) {
+	if !Enabled(WarnLevel) {
+		return
+	}
+
 	entry := &Entry{}
 	entry.Warn(args...)
 }
 
 func Error(args ...interface{}) {
+	if !Enabled(ErrorLevel) {
+		return
+	}
+
 	entry := &Entry{}
 	entry.Error(args...)
 }
 
+func Fatal(args ...interface{}) {
+	entry := &Entry{}
+	entry.Fatal(args...)
+}
+
+func Panic(args ...interface{}) {
+	entry := &Entry{}
+	entry.Panic(args...)
+}
+
+func Reset() {
+	Close()
+	dedupe = false
+
+	timeFormat = "[2006-01-02 15:04:05]"
+	levelFormat = "[%s]"
+	showIcons = true
+	output = os.Stdout
+	errOutput = nil
+	now = time.Now
+	defaults = nil
+	colorMode = ColorNever
+	hooks = nil
+	minLevel = LevelDebug
+	fieldFormat = nil
+	ctxFields = nil
+	levelIcons = nil
+	indent = ""
+	timeMode = TimeLayout
+	timeUtc = false
+	stackTraces = true
+	newline = true
+	flatten = false
+
+	initOnce = sync.Once{}
+	formatter = TextFormatter{}
+	prefix = ""
+	errorArgs = true
+	exitFunc = os.Exit
+	timeFields = true
+	processors = nil
+	fieldLayout = ""
+
+	resetJSON()
+	resetLimits()
+}
+
 func Init(opts ...LoggerOption) {
 	for _, opt := range opts {
 		opt()
 	}
 }
+
+func InitOnce(opts ...LoggerOption) (applied bool) {
+	initOnce.Do(func() {
+		Init(opts...)
+		applied = true
+	})
+	return
+}
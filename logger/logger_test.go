@@ -0,0 +1,486 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pritunl/tools/errors"
+)
+
+type syncWriter struct {
+	lock    sync.Mutex
+	active  int32
+	overlap int32
+	writes  []string
+}
+
+func (w *syncWriter) Write(p []byte) (n int, err error) {
+	if !atomic.CompareAndSwapInt32(&w.active, 0, 1) {
+		atomic.StoreInt32(&w.overlap, 1)
+	} else {
+		defer atomic.StoreInt32(&w.active, 0)
+	}
+
+	runtime.Gosched()
+
+	w.lock.Lock()
+	w.writes = append(w.writes, string(p))
+	w.lock.Unlock()
+
+	n = len(p)
+	return
+}
+
+// Resets the logger and captures its output with the text time prefix
+// disabled, the given options are applied after.
+func captureLogs(t *testing.T, opts ...LoggerOption) *syncWriter {
+	t.Helper()
+
+	Reset()
+	t.Cleanup(Reset)
+
+	writer := &syncWriter{}
+	Init(
+		SetOutput(writer),
+		SetTimeFormat(""),
+	)
+	Init(opts...)
+
+	return writer
+}
+
+func assertWrites(t *testing.T, w *syncWriter, expected ...string) {
+	t.Helper()
+
+	w.lock.Lock()
+	writes := w.writes
+	w.lock.Unlock()
+
+	if len(writes) != len(expected) {
+		t.Fatalf("logger: Expected %q, got %q", expected, writes)
+	}
+
+	for i, write := range writes {
+		if write != expected[i] {
+			t.Errorf("logger: Expected %q, got %q", expected[i], write)
+		}
+	}
+}
+
+var entryReg = regexp.MustCompile(`^\[INFO\] ▶ entry (\d+)-(\d+)\n`)
+
+func testAtomicWrites(t *testing.T, async bool) {
+	writer := captureLogs(t)
+	if async {
+		Init(SetAsync(16))
+	}
+
+	goroutines := 8
+	entries := 100
+
+	waiter := sync.WaitGroup{}
+	for g := 0; g < goroutines; g++ {
+		waiter.Add(1)
+		go func(g int) {
+			defer waiter.Done()
+
+			for i := 0; i < entries; i++ {
+				WithFields(Fields{
+					"goroutine": g,
+					"error": fmt.Errorf(
+						"error %d-%d\nsecond error line", g, i),
+				}).Info(fmt.Sprintf("entry %d-%d\nsecond line", g, i))
+			}
+		}(g)
+	}
+	waiter.Wait()
+
+	Close()
+
+	if atomic.LoadInt32(&writer.overlap) != 0 {
+		t.Error("logger: Concurrent calls to Write")
+	}
+
+	if len(writer.writes) != goroutines*entries {
+		t.Fatalf("logger: Expected %d writes, got %d",
+			goroutines*entries, len(writer.writes))
+	}
+
+	seen := map[string]bool{}
+	for _, write := range writer.writes {
+		match := entryReg.FindStringSubmatch(write)
+		if match == nil {
+			t.Fatalf("logger: Write does not start an entry %q", write)
+		}
+
+		g, _ := strconv.Atoi(match[1])
+		i, _ := strconv.Atoi(match[2])
+
+		expected := fmt.Sprintf(
+			"[INFO] ▶ entry %d-%d\nsecond line ◆ goroutine=%d\n"+
+				"error %d-%d\nsecond error line\n",
+			g, i, g, g, i,
+		)
+		if write != expected {
+			t.Fatalf("logger: Write is not one whole entry %q", write)
+		}
+
+		if seen[expected] {
+			t.Fatalf("logger: Duplicate entry %q", write)
+		}
+		seen[expected] = true
+	}
+}
+
+func TestAtomicWrites(t *testing.T) {
+	t.Run("sync", func(t *testing.T) {
+		testAtomicWrites(t, false)
+	})
+	t.Run("async", func(t *testing.T) {
+		testAtomicWrites(t, true)
+	})
+}
+
+func TestSampleSuppressed(t *testing.T) {
+	writer := captureLogs(t)
+
+	for i := 0; i < 8; i++ {
+		WithFields(nil).Sample(3).Info("sampled")
+	}
+	Flush()
+
+	assertWrites(t, writer,
+		"[INFO] ▶ sampled\n",
+		"[INFO] ▶ sampled ◆ suppressed=2\n",
+		"[INFO] ▶ sampled ◆ suppressed=2\n",
+		"[INFO] ▶ sampled ◆ suppressed=1\n",
+	)
+}
+
+type fieldsFormatter struct {
+	fields []Fields
+}
+
+func (f *fieldsFormatter) Format(e *Entry) ([]byte, error) {
+	f.fields = append(f.fields, e.Data)
+	return []byte(e.Message), nil
+}
+
+func TestFormatterDefaultFields(t *testing.T) {
+	fmtr := &fieldsFormatter{}
+	captureLogs(t,
+		SetFormatter(fmtr),
+		SetDefaultFields(Fields{
+			"service": "api",
+			"version": "1",
+		}),
+	)
+
+	fields := Fields{
+		"version": "2",
+	}
+	WithFields(fields).Info("entry")
+
+	expected := []Fields{{
+		"service": "api",
+		"version": "2",
+	}}
+	if !reflect.DeepEqual(fmtr.fields, expected) {
+		t.Errorf("logger: Expected %v, got %v", expected, fmtr.fields)
+	}
+
+	if len(fields) != 1 {
+		t.Errorf("logger: Caller fields modified %v", fields)
+	}
+}
+
+type fieldsHook struct {
+	fields []Fields
+}
+
+func (h *fieldsHook) Levels() []string {
+	return []string{ErrorLevel}
+}
+
+func (h *fieldsHook) Fire(e *Entry) error {
+	h.fields = append(h.fields, e.Data)
+	return nil
+}
+
+func TestHookDefaultFields(t *testing.T) {
+	hook := &fieldsHook{}
+	captureLogs(t,
+		AddHook(hook),
+		SetDefaultFields(Fields{
+			"service": "api",
+			"version": "1",
+		}),
+	)
+
+	Info("skipped")
+	WithFields(Fields{
+		"code": 500,
+	}).Error("failed")
+
+	expected := []Fields{{
+		"code":    500,
+		"service": "api",
+		"version": "1",
+	}}
+	if !reflect.DeepEqual(hook.fields, expected) {
+		t.Errorf("logger: Expected %v, got %v", expected, hook.fields)
+	}
+}
+
+func TestDedupeWriters(t *testing.T) {
+	writer := captureLogs(t,
+		SetDedupe(true),
+	)
+	audit := &syncWriter{}
+
+	Info("same")
+	WithFields(nil).ToWriter(audit).Info("same")
+	Info("same")
+	Info("same")
+	Flush()
+
+	assertWrites(t, writer,
+		"[INFO] ▶ same\n",
+		"[INFO] ▶ same\n",
+		"[INFO] ▶ Last message repeated 1 times\n",
+	)
+	assertWrites(t, audit,
+		"[INFO] ▶ same\n",
+	)
+}
+
+type chanHook chan *Entry
+
+func (h chanHook) Levels() []string {
+	return []string{InfoLevel}
+}
+
+func (h chanHook) Fire(e *Entry) error {
+	h <- e
+	return nil
+}
+
+func TestSampleInterval(t *testing.T) {
+	hook := make(chanHook, 2)
+	writer := captureLogs(t,
+		AddHook(hook),
+	)
+
+	interval := sampleInterval
+	sampleInterval = 10 * time.Millisecond
+	defer func() {
+		sampleInterval = interval
+	}()
+
+	for i := 0; i < 5; i++ {
+		WithFields(nil).Sample(10).Info("sampled")
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-hook:
+		case <-time.After(time.Second):
+			t.Fatal("logger: Timed out waiting for sample flush")
+		}
+	}
+
+	assertWrites(t, writer,
+		"[INFO] ▶ sampled\n",
+		"[INFO] ▶ sampled ◆ suppressed=4\n",
+	)
+}
+
+func TestTimeFields(t *testing.T) {
+	writer := captureLogs(t)
+
+	fields := Fields{
+		"at":   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		"took": 1500 * time.Millisecond,
+	}
+	WithFields(fields).Info("times")
+
+	Init(SetTimeFieldFormat("15:04"))
+	WithFields(fields).Info("times")
+
+	assertWrites(t, writer,
+		"[INFO] ▶ times ◆ at=2026-01-02T03:04:05Z ◆ took=1.5s\n",
+		"[INFO] ▶ times ◆ at=03:04 ◆ took=1.5s\n",
+	)
+}
+
+func TestFlattenFields(t *testing.T) {
+	writer := captureLogs(t,
+		SetFlattenFields(true),
+	)
+
+	WithFields(Fields{
+		"user": map[string]interface{}{
+			"id":   1,
+			"tags": map[string]string{},
+		},
+	}).Info("flatten")
+
+	assertWrites(t, writer,
+		"[INFO] ▶ flatten ◆ user.id=1 ◆ "+
+			"user.tags=map[string]string{}\n",
+	)
+}
+
+func TestJSONReservedKeys(t *testing.T) {
+	writer := captureLogs(t,
+		SetJSON(true),
+		SetJSONKeys("msg", "level", "time"),
+		SetNowFunc(func() time.Time {
+			return time.Unix(0, 0).UTC()
+		}),
+	)
+
+	WithFields(Fields{
+		"msg":    "user message",
+		"level":  5,
+		"prefix": "user prefix",
+		"other":  true,
+	}).Info("entry")
+
+	Init(SetPrefix("worker"))
+
+	WithFields(Fields{
+		"prefix": "user prefix",
+	}).Info("entry")
+
+	assertWrites(t, writer,
+		`{"fields.level":5,"fields.msg":"user message",`+
+			`"level":"info","msg":"entry","other":true,`+
+			`"prefix":"user prefix","time":"1970-01-01T00:00:00Z"}`+"\n",
+		`{"fields.prefix":"user prefix","level":"info","msg":"entry",`+
+			`"prefix":"worker","time":"1970-01-01T00:00:00Z"}`+"\n",
+	)
+}
+
+func TestJSONErrorChain(t *testing.T) {
+	writer := captureLogs(t,
+		SetJSON(true),
+		SetErrorChain(true),
+		SetNowFunc(func() time.Time {
+			return time.Unix(0, 0).UTC()
+		}),
+	)
+
+	err := errors.Wrap(fmt.Errorf("root"), "outer")
+	WithError(err).Error("failed")
+
+	assertWrites(t, writer,
+		`{"error":[{"message":"outer"},{"message":"root"}],`+
+			`"level":"error","message":"failed",`+
+			`"time":"1970-01-01T00:00:00Z"}`+"\n",
+	)
+}
+
+func TestErrorArgs(t *testing.T) {
+	writer := captureLogs(t,
+		SetStackTraces(false),
+	)
+
+	Error(fmt.Errorf("solo"))
+	Error(errors.Wrap(fmt.Errorf("inner"), "outer"))
+	Error("failed ", fmt.Errorf("trailing"))
+
+	var pathErr *os.PathError
+	Error("typed nil ", pathErr)
+	WithFields(Fields{
+		"error": pathErr,
+	}).Error("typed nil field")
+
+	assertWrites(t, writer,
+		"[ERROR] ▶ solo\nsolo\n",
+		"[ERROR] ▶ outer\nouter\ninner\n",
+		"[ERROR] ▶ failed \ntrailing\n",
+		"[ERROR] ▶ typed nil <nil>\n",
+		"[ERROR] ▶ typed nil field\n<nil>\n",
+	)
+}
+
+func TestStackTraces(t *testing.T) {
+	wrapped := fmt.Errorf("ctx: %w", errors.New("boom"))
+
+	writer := captureLogs(t)
+	WithError(wrapped).Error("failed")
+
+	if len(writer.writes) != 1 {
+		t.Fatalf("logger: Expected 1 write, got %q", writer.writes)
+	}
+	if n := strings.Count(writer.writes[0], "STACK TRACE"); n != 1 {
+		t.Errorf("logger: Expected 1 stack trace, got %d", n)
+	}
+
+	var pathErr *os.PathError
+	writer = captureLogs(t,
+		SetStackTraces(false),
+	)
+	WithError(wrapped).Error("failed")
+	WithError(pathErr).Error("typed nil")
+
+	assertWrites(t, writer,
+		"[ERROR] ▶ failed\nctx: boom\n",
+		"[ERROR] ▶ typed nil\n<nil>\n",
+	)
+}
+
+func TestPanicFatalUnfiltered(t *testing.T) {
+	exitCode := -1
+	writer := captureLogs(t,
+		SetLevel(LevelPanic+1),
+		SetExitFunc(func(code int) {
+			exitCode = code
+		}),
+	)
+
+	Fatal("fatal message")
+
+	func() {
+		defer func() {
+			val := recover()
+			if val != "panic message" {
+				t.Errorf("logger: Bad panic value %#v", val)
+			}
+		}()
+		Panic("panic message")
+	}()
+
+	if exitCode != 1 {
+		t.Errorf("logger: Bad exit code %d", exitCode)
+	}
+
+	assertWrites(t, writer,
+		"[FATAL] ▶ fatal message\n",
+		"[PANIC] ▶ panic message\n",
+	)
+}
+
+func BenchmarkDisabledDebug(b *testing.B) {
+	Reset()
+	defer Reset()
+
+	Init(SetLevel(LevelInfo))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		Debug("disabled debug message")
+	}
+}
@@ -0,0 +1,152 @@
+package logtest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pritunl/tools/errors"
+	"github.com/pritunl/tools/logger"
+)
+
+type Entry struct {
+	Level   string
+	Message string
+	Fields  map[string]string
+	Error   string
+	Raw     string
+}
+
+type Recorder struct {
+	lock    sync.Mutex
+	entries []*Entry
+	output  []byte
+}
+
+// Records each entry as it is formatted with the default text format, so
+// entry boundaries are exact even for multi-line messages and errors.
+func (r *Recorder) Format(e *logger.Entry) ([]byte, error) {
+	raw, err := logger.TextFormatter{}.Format(e)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{
+		Level:   e.Level,
+		Message: e.Message,
+		Fields:  map[string]string{},
+		Raw:     string(raw),
+	}
+
+	for key, val := range e.Data {
+		if key == "error" {
+			err, ok := val.(error)
+			if ok && !isNilError(err) {
+				entry.Error = errors.GetMessage(err)
+			} else {
+				entry.Error = fmt.Sprint(val)
+			}
+			continue
+		}
+		entry.Fields[key] = fmt.Sprint(val)
+	}
+
+	r.lock.Lock()
+	r.entries = append(r.entries, entry)
+	r.lock.Unlock()
+
+	return raw, nil
+}
+
+func isNilError(err error) bool {
+	val := reflect.ValueOf(err)
+	return val.Kind() == reflect.Ptr && val.IsNil()
+}
+
+func (r *Recorder) Write(p []byte) (n int, err error) {
+	r.lock.Lock()
+	r.output = append(r.output, p...)
+	r.lock.Unlock()
+
+	n = len(p)
+	return
+}
+
+// Resets the logger to its defaults then sends all output to the recorder.
+// Any configuration applied before this option is lost, apply additional
+// options after it.
+func (r *Recorder) Option() logger.LoggerOption {
+	return func() {
+		logger.Reset()
+		logger.Init(
+			logger.SetOutput(r),
+			logger.SetErrorOutput(r),
+			logger.SetFormatter(r),
+		)
+	}
+}
+
+// Applies Option and restores the logger defaults when the test finishes.
+func (r *Recorder) Install(t testing.TB) {
+	logger.Init(r.Option())
+	t.Cleanup(logger.Reset)
+}
+
+func (r *Recorder) Entries() []*Entry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entries := make([]*Entry, len(r.entries))
+	copy(entries, r.entries)
+
+	return entries
+}
+
+func (r *Recorder) Output() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return string(r.output)
+}
+
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	r.entries = nil
+	r.output = nil
+	r.lock.Unlock()
+}
+
+func (r *Recorder) Contains(level, substr string) bool {
+	for _, entry := range r.Entries() {
+		if level != "" && entry.Level != level {
+			continue
+		}
+		if strings.Contains(entry.Message, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Recorder) AssertContains(t testing.TB, level, substr string) {
+	t.Helper()
+
+	if !r.Contains(level, substr) {
+		t.Errorf("logtest: No %s entry containing '%s'", level, substr)
+	}
+}
+
+func (r *Recorder) AssertNotContains(t testing.TB, level, substr string) {
+	t.Helper()
+
+	if r.Contains(level, substr) {
+		t.Errorf("logtest: Unexpected %s entry containing '%s'",
+			level, substr)
+	}
+}
+
+func New() *Recorder {
+	return &Recorder{}
+}
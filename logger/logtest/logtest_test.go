@@ -0,0 +1,79 @@
+package logtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pritunl/tools/errors"
+	"github.com/pritunl/tools/logger"
+)
+
+func TestRecorder(t *testing.T) {
+	logger.Init(
+		logger.SetJSON(true),
+		logger.SetPrefix("[test]"),
+		logger.SetLevel(logger.LevelError),
+		logger.SetDedupe(true),
+		logger.AddProcessor(func(*logger.Entry) bool {
+			return false
+		}),
+	)
+
+	rec := New()
+	rec.Install(t)
+	logger.Init(logger.SetDefaultFields(logger.Fields{
+		"service": "api",
+	}))
+
+	logger.Info("multi\nline")
+	logger.Info("multi\nline")
+	logger.WithFields(logger.Fields{
+		"count": 3,
+		"name":  "value",
+		"error": errors.New("failed\nbadly"),
+	}).Warn("with\nerror")
+
+	entries := rec.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("logtest: Expected 3 entries, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Level != logger.InfoLevel {
+		t.Errorf("logtest: Bad level %q", entry.Level)
+	}
+	if entry.Message != "multi\nline" {
+		t.Errorf("logtest: Bad message %q", entry.Message)
+	}
+	if entry.Error != "" {
+		t.Errorf("logtest: Unexpected error %q", entry.Error)
+	}
+	if strings.HasPrefix(entry.Raw, "[test]") ||
+		strings.HasPrefix(entry.Raw, "{") {
+
+		t.Errorf("logtest: Raw not in default text format %q", entry.Raw)
+	}
+
+	entry = entries[2]
+	if entry.Level != logger.WarnLevel {
+		t.Errorf("logtest: Bad level %q", entry.Level)
+	}
+	if entry.Message != "with\nerror" {
+		t.Errorf("logtest: Bad message %q", entry.Message)
+	}
+	if entry.Error != "failed\nbadly" {
+		t.Errorf("logtest: Bad error %q", entry.Error)
+	}
+	if entry.Fields["count"] != "3" || entry.Fields["name"] != "value" ||
+		entry.Fields["service"] != "api" {
+
+		t.Errorf("logtest: Bad fields %v", entry.Fields)
+	}
+
+	if rec.Output() != entries[0].Raw+entries[1].Raw+entries[2].Raw {
+		t.Errorf("logtest: Output does not match entries %q", rec.Output())
+	}
+
+	rec.AssertContains(t, logger.InfoLevel, "multi")
+	rec.AssertNotContains(t, logger.ErrorLevel, "multi")
+}
@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"time"
+)
+
+type Logger struct {
+	level Level
+}
+
+func (l *Logger) entry(fields Fields) *Entry {
+	level := l.level
+	return &Entry{
+		Data:     fields,
+		minLevel: &level,
+	}
+}
+
+func (l *Logger) WithFields(fields Fields) *Entry {
+	return l.entry(fields)
+}
+
+func (l *Logger) Limit(dur time.Duration) *Entry {
+	return l.entry(nil).Limit(dur)
+}
+
+func (l *Logger) Debug(args ...interface{}) {
+	l.entry(nil).Debug(args...)
+}
+
+func (l *Logger) Info(args ...interface{}) {
+	l.entry(nil).Info(args...)
+}
+
+func (l *Logger) Warn(args ...interface{}) {
+	l.entry(nil).Warn(args...)
+}
+
+func (l *Logger) Error(args ...interface{}) {
+	l.entry(nil).Error(args...)
+}
+
+func WithLevel(level Level) *Logger {
+	return &Logger{
+		level: level,
+	}
+}
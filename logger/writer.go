@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+type LineWriter struct {
+	level string
+	entry Entry
+	buf   []byte
+	lock  sync.Mutex
+}
+
+func (w *LineWriter) emit(line []byte) {
+	entry := w.entry
+	entry.log(w.level, strings.TrimSuffix(string(line), "\r"))
+}
+
+func (w *LineWriter) Write(p []byte) (n int, err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+
+	n = len(p)
+	return
+}
+
+func (w *LineWriter) Flush() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.buf) == 0 {
+		return
+	}
+
+	w.emit(w.buf)
+	w.buf = nil
+}
+
+func (e *Entry) Writer(level string) *LineWriter {
+	return &LineWriter{
+		level: level,
+		entry: Entry{
+			Data:     e.Data,
+			dest:     e.dest,
+			minLevel: e.minLevel,
+		},
+	}
+}
+
+func Writer(level string) *LineWriter {
+	return &LineWriter{
+		level: level,
+	}
+}